@@ -0,0 +1,248 @@
+package v2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// APIVersion is the value sent in the X-Broker-Api-Version header with every
+// request, as required by the Open Service Broker API specification.
+type APIVersion string
+
+// Version2_14 is the API version implemented by this client.
+const Version2_14 APIVersion = "2.14"
+
+// ClientConfiguration contains the configuration required to instantiate a
+// new Client.
+type ClientConfiguration struct {
+	// Name identifies this client in diagnostics; it has no protocol
+	// significance.
+	Name string
+	// URL is the base URL of the broker, with no trailing slash.
+	URL string
+	// APIVersion is the X-Broker-Api-Version sent with every request.
+	APIVersion APIVersion
+	// HTTPClient is used to make requests to the broker. http.DefaultClient
+	// is used if this is nil.
+	HTTPClient *http.Client
+
+	// ResponseErrorHandler is invoked for any broker response whose status
+	// code indicates failure. DefaultResponseErrorHandler is used if this is
+	// nil.
+	ResponseErrorHandler ResponseErrorHandler
+	// ResponseDecoder is invoked to decode a successful broker response
+	// body. DefaultResponseDecoder is used if this is nil.
+	ResponseDecoder ResponseDecoder
+
+	// RetryPolicy is applied to the client's idempotent operations:
+	// GetCatalog, PollLastOperation, PollBindingLastOperation and
+	// DeprovisionInstance. The zero value disables retrying, since
+	// RetryPolicy.MaxAttempts of less than 1 is treated as a single
+	// attempt.
+	RetryPolicy RetryPolicy
+}
+
+// Client is a client for the Open Service Broker API.
+type Client struct {
+	name       string
+	url        string
+	apiVersion APIVersion
+
+	httpClient           *http.Client
+	responseErrorHandler ResponseErrorHandler
+	responseDecoder      ResponseDecoder
+	retryPolicy          RetryPolicy
+}
+
+// NewClient creates a Client from the given configuration, filling in
+// DefaultResponseErrorHandler, DefaultResponseDecoder and http.DefaultClient
+// for any hook that was left unset.
+func NewClient(config *ClientConfiguration) *Client {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	responseErrorHandler := config.ResponseErrorHandler
+	if responseErrorHandler == nil {
+		responseErrorHandler = DefaultResponseErrorHandler
+	}
+
+	responseDecoder := config.ResponseDecoder
+	if responseDecoder == nil {
+		responseDecoder = DefaultResponseDecoder
+	}
+
+	return &Client{
+		name:                 config.Name,
+		url:                  config.URL,
+		apiVersion:           config.APIVersion,
+		httpClient:           httpClient,
+		responseErrorHandler: responseErrorHandler,
+		responseDecoder:      responseDecoder,
+		retryPolicy:          config.RetryPolicy,
+	}
+}
+
+// CatalogResponse is the response to a GetCatalog request.
+type CatalogResponse struct {
+	Services []Service `json:"services"`
+}
+
+// Service is a single entry in a CatalogResponse.
+type Service struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// GetCatalog returns the broker's catalog of services. It is retried
+// according to c.retryPolicy, since fetching the catalog is idempotent.
+func (c *Client) GetCatalog() (*CatalogResponse, error) {
+	var response CatalogResponse
+	if err := c.doIdempotent(http.MethodGet, "/v2/catalog", nil, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// DeprovisionInstance deprovisions a service instance. It is retried
+// according to c.retryPolicy, since deprovisioning is idempotent.
+func (c *Client) DeprovisionInstance(instanceID, serviceID, planID string) error {
+	path := fmt.Sprintf("/v2/service_instances/%s?%s", instanceID, url.Values{
+		"service_id": {serviceID},
+		"plan_id":    {planID},
+	}.Encode())
+
+	return c.doIdempotent(http.MethodDelete, path, nil, nil)
+}
+
+// LastOperationResponse is the response to a PollLastOperation or
+// PollBindingLastOperation request.
+type LastOperationResponse struct {
+	State       LastOperationState `json:"state"`
+	Description *string            `json:"description,omitempty"`
+}
+
+// LastOperationState is the broker-reported state of an asynchronous
+// operation.
+type LastOperationState string
+
+const (
+	StateInProgress LastOperationState = "in progress"
+	StateSucceeded  LastOperationState = "succeeded"
+	StateFailed     LastOperationState = "failed"
+)
+
+// OperationKey is an opaque identifier a broker returns for an asynchronous
+// operation, to be passed back when polling for its status.
+type OperationKey string
+
+// PollLastOperation polls the status of an asynchronous provision, update or
+// deprovision operation for the given service instance. It is retried
+// according to c.retryPolicy, since polling is idempotent.
+func (c *Client) PollLastOperation(instanceID string, op *OperationKey) (*LastOperationResponse, error) {
+	path := fmt.Sprintf("/v2/service_instances/%s/last_operation", instanceID)
+	if op != nil {
+		path += "?" + url.Values{"operation": {string(*op)}}.Encode()
+	}
+
+	var response LastOperationResponse
+	if err := c.doIdempotent(http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// PollBindingLastOperation polls the status of an asynchronous bind or
+// unbind operation for the given service instance and binding, introduced in
+// OSB 2.14. It is retried according to c.retryPolicy, since polling is
+// idempotent.
+//
+// Brokers use two distinct failure statuses here that callers must not
+// conflate: a 410 Gone response (IsGoneError) means the binding has already
+// been removed, which is the expected terminal state of a successful unbind
+// poll and should be treated as success by the caller's state machine. A
+// 404 Not Found response (IsNotFoundError) means the broker has no record of
+// the binding at all, which is a caller error (e.g. polling the wrong
+// binding ID) rather than a completed unbind.
+func (c *Client) PollBindingLastOperation(instanceID, bindingID string, op *OperationKey) (*LastOperationResponse, error) {
+	path := fmt.Sprintf("/v2/service_instances/%s/service_bindings/%s/last_operation", instanceID, bindingID)
+	if op != nil {
+		path += "?" + url.Values{"operation": {string(*op)}}.Encode()
+	}
+
+	var response LastOperationResponse
+	if err := c.doIdempotent(http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// request builds an *http.Request for the given method and path against the
+// broker's base URL, marshalling body as the JSON request body when it is
+// non-nil.
+func (c *Client) request(method, path string, body interface{}) (*http.Request, error) {
+	var reader *bytes.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Broker-Api-Version", string(c.apiVersion))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req, nil
+}
+
+// do sends a single request and, on success, decodes the response body into
+// out via c.responseDecoder. On failure it returns the error built by
+// c.responseErrorHandler.
+func (c *Client) do(method, path string, body interface{}, out interface{}) error {
+	req, err := c.request(method, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return c.responseErrorHandler(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return c.responseDecoder(resp, out)
+}
+
+// doIdempotent is like do, but runs the request through c.retryPolicy so
+// that idempotent operations transparently retry on transient errors (see
+// IsTransientError).
+func (c *Client) doIdempotent(method, path string, body interface{}, out interface{}) error {
+	return c.retryPolicy.Do(func() error {
+		return c.do(method, path, body, out)
+	})
+}
@@ -0,0 +1,289 @@
+package v2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientUsesDefaultResponseHandling(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/catalog":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"services":[{"name":"fake-service","id":"fake-service-id"}]}`))
+		case "/v2/service_instances/instance-1":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"error":"ConcurrencyError","description":"The broker is already processing a request for the resource in question. The client should retry later."}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(&ClientConfiguration{URL: server.URL})
+
+	catalog, err := client.GetCatalog()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(catalog.Services) != 1 || catalog.Services[0].ID != "fake-service-id" {
+		t.Errorf("GetCatalog() = %+v, want one service with ID fake-service-id", catalog)
+	}
+
+	err = client.DeprovisionInstance("instance-1", "service-id", "plan-id")
+	if !IsConcurrencyError(err) {
+		t.Fatalf("expected a concurrency error from DefaultResponseErrorHandler, got %v", err)
+	}
+}
+
+func TestClientInvokesConfiguredResponseErrorHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("irrelevant body"))
+	}))
+	defer server.Close()
+
+	var errorHandlerCalled, decoderCalled bool
+
+	client := NewClient(&ClientConfiguration{
+		URL: server.URL,
+		ResponseErrorHandler: func(resp *http.Response) error {
+			errorHandlerCalled = true
+			return fmt.Errorf("custom handler saw status %d", resp.StatusCode)
+		},
+		ResponseDecoder: func(resp *http.Response, v interface{}) error {
+			decoderCalled = true
+			return DefaultResponseDecoder(resp, v)
+		},
+	})
+
+	_, err := client.GetCatalog()
+	if !errorHandlerCalled {
+		t.Error("configured ResponseErrorHandler was never invoked")
+	}
+	if decoderCalled {
+		t.Error("ResponseDecoder should not be invoked on a non-2xx response")
+	}
+	if want := fmt.Sprintf("custom handler saw status %d", http.StatusTeapot); err == nil || err.Error() != want {
+		t.Errorf("GetCatalog() error = %v, want %q", err, want)
+	}
+}
+
+func TestClientInvokesConfiguredResponseDecoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"services":[{"name":"fake-service","id":"fake-service-id"}]}`))
+	}))
+	defer server.Close()
+
+	var decoderCalled bool
+
+	client := NewClient(&ClientConfiguration{
+		URL: server.URL,
+		ResponseDecoder: func(resp *http.Response, v interface{}) error {
+			decoderCalled = true
+			return DefaultResponseDecoder(resp, v)
+		},
+	})
+
+	catalog, err := client.GetCatalog()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !decoderCalled {
+		t.Error("configured ResponseDecoder was never invoked")
+	}
+	if len(catalog.Services) != 1 {
+		t.Errorf("GetCatalog() = %+v, want one service", catalog)
+	}
+}
+
+// TestClientRetriesIdempotentCallsOnTransientErrors drives GetCatalog,
+// PollLastOperation and DeprovisionInstance against a fake broker that fails
+// with a transient status a fixed number of times before succeeding, to
+// prove that RetryPolicy is actually wired through doIdempotent end-to-end
+// rather than only unit-tested against a fake operation func.
+func TestClientRetriesIdempotentCallsOnTransientErrors(t *testing.T) {
+	cases := []struct {
+		name        string
+		failures    int
+		failStatus  int
+		do          func(client *Client) error
+		expectCalls int32
+	}{
+		{
+			name:        "GetCatalog retries 503 then succeeds",
+			failures:    2,
+			failStatus:  http.StatusServiceUnavailable,
+			do:          func(client *Client) error { _, err := client.GetCatalog(); return err },
+			expectCalls: 3,
+		},
+		{
+			name:        "PollLastOperation retries 429 then succeeds",
+			failures:    1,
+			failStatus:  http.StatusTooManyRequests,
+			do:          func(client *Client) error { _, err := client.PollLastOperation("instance-1", nil); return err },
+			expectCalls: 2,
+		},
+		{
+			name:        "DeprovisionInstance retries 503 then succeeds",
+			failures:    2,
+			failStatus:  http.StatusServiceUnavailable,
+			do:          func(client *Client) error { return client.DeprovisionInstance("instance-1", "service-id", "plan-id") },
+			expectCalls: 3,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var calls int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&calls, 1)
+				if n <= int32(c.failures) {
+					w.WriteHeader(c.failStatus)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				switch {
+				case r.URL.Path == "/v2/catalog":
+					w.Write([]byte(`{"services":[]}`))
+				case r.Method == http.MethodDelete:
+				default:
+					w.Write([]byte(`{"state":"succeeded"}`))
+				}
+			}))
+			defer server.Close()
+
+			client := NewClient(&ClientConfiguration{
+				URL: server.URL,
+				RetryPolicy: RetryPolicy{
+					MaxAttempts:     5,
+					BackoffInterval: time.Millisecond,
+					MaxBackoff:      2 * time.Millisecond,
+				},
+			})
+
+			if err := c.do(client); err != nil {
+				t.Fatalf("unexpected error after retrying: %v", err)
+			}
+			if got := atomic.LoadInt32(&calls); got != c.expectCalls {
+				t.Errorf("calls = %d, want %d", got, c.expectCalls)
+			}
+		})
+	}
+}
+
+// TestClientGivesUpAfterMaxAttempts proves MaxAttempts is honored
+// end-to-end: a server that always fails transiently should only be hit
+// MaxAttempts times before the client surfaces the final error.
+func TestClientGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(&ClientConfiguration{
+		URL: server.URL,
+		RetryPolicy: RetryPolicy{
+			MaxAttempts:     3,
+			BackoffInterval: time.Millisecond,
+			MaxBackoff:      2 * time.Millisecond,
+		},
+	})
+
+	_, err := client.GetCatalog()
+	if !IsServerTimeoutError(err) {
+		t.Fatalf("expected a final server timeout error, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
+
+// TestClientHonorsRetryAfterFromServer proves that a broker-supplied
+// Retry-After header reaches RetryPolicy through the real response path,
+// not just through a hand-built HTTPStatusCodeError in a unit test.
+func TestClientHonorsRetryAfterFromServer(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", strconv.Itoa(0))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"services":[]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&ClientConfiguration{
+		URL: server.URL,
+		RetryPolicy: RetryPolicy{
+			MaxAttempts:     2,
+			BackoffInterval: time.Hour,
+			HonorRetryAfter: true,
+		},
+	})
+
+	start := time.Now()
+	if _, err := client.GetCatalog(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("elapsed = %v, want well under the 1h BackoffInterval since Retry-After: 0 should have been honored", elapsed)
+	}
+}
+
+// TestPollBindingLastOperationDistinguishesGoneFromNotFound proves that a
+// 410 response from a fake broker surfaces through PollBindingLastOperation
+// as IsGoneError (and not IsNotFoundError), and a 404 response surfaces as
+// IsNotFoundError (and not IsGoneError) -- the distinction this request's
+// title promises.
+func TestPollBindingLastOperationDistinguishesGoneFromNotFound(t *testing.T) {
+	cases := []struct {
+		name          string
+		bindingID     string
+		status        int
+		wantGoneError bool
+		wantNotFound  bool
+	}{
+		{name: "410 Gone means the binding already finished unbinding", bindingID: "already-removed", status: http.StatusGone, wantGoneError: true, wantNotFound: false},
+		{name: "404 Not Found means the broker never heard of the binding", bindingID: "unknown", status: http.StatusNotFound, wantGoneError: false, wantNotFound: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(c.status)
+			}))
+			defer server.Close()
+
+			client := NewClient(&ClientConfiguration{URL: server.URL})
+
+			_, err := client.PollBindingLastOperation("instance-1", c.bindingID, nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if IsGoneError(err) != c.wantGoneError {
+				t.Errorf("IsGoneError(err) = %v, want %v", IsGoneError(err), c.wantGoneError)
+			}
+			if IsNotFoundError(err) != c.wantNotFound {
+				t.Errorf("IsNotFoundError(err) = %v, want %v", IsNotFoundError(err), c.wantNotFound)
+			}
+		})
+	}
+}
@@ -1,10 +1,19 @@
 package v2
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"time"
 )
 
+// ProblemDetailsContentType is the media type that identifies an RFC 7807
+// "Problem Details" error body, as returned by some brokers (and by the
+// proxies that sit in front of them) instead of the plain OSB error shape.
+const ProblemDetailsContentType = "application/problem+json"
+
 // HTTPStatusCodeError is an error type that provides additional information
 // based on the Open Service Broker API conventions for returning information
 // about errors.  If the response body provided by the broker to any client
@@ -14,15 +23,27 @@ import (
 // These errors may optionally provide a machine-readable error message and
 // human-readable description.
 //
-// The IsHTTPError method checks whether an error is of this type.
+// The IsHTTPError method checks whether an error is of this type.  It, and
+// the rest of the IsXxx family below, work through errors.Is/errors.As, so
+// they still recognize the error after it has been wrapped with
+// fmt.Errorf("...: %w", err).  Exported sentinels (ErrGone, ErrConflict,
+// ErrAsyncRequired, ErrAppGUIDRequired, ErrConcurrency) are also available
+// for direct use with errors.Is.
 //
 // Checks for important errors in the API specification are implemented as
 // utility methods:
 //
 // - IsGoneError
+// - IsNotFoundError
 // - IsConflictError
 // - IsAsyncRequiredError
+// - IsAsyncBindingRequiredError
 // - IsAppGUIDRequiredError
+// - IsConcurrencyError
+// - IsProblemDetails
+// - IsThrottledError
+// - IsServerTimeoutError
+// - IsTransientError
 type HTTPStatusCodeError struct {
 	// StatusCode is the HTTP status code returned by the broker.
 	StatusCode int
@@ -35,38 +56,187 @@ type HTTPStatusCodeError struct {
 	// ResponseError is set to the error that occured when unmarshalling a
 	// response body from the broker.
 	ResponseError error
+
+	// Type, Title, Detail and Instance are populated instead of ErrorMessage
+	// and Description when the broker returns an RFC 7807 "Problem Details"
+	// error body (Content-Type: application/problem+json).  Type is a URI
+	// reference identifying the problem type, Title is a short human-readable
+	// summary, Detail is a human-readable explanation specific to this
+	// occurrence, and Instance is a URI reference identifying the specific
+	// occurrence of the problem.
+	Type     *string
+	Title    *string
+	Detail   *string
+	Instance *string
+
+	// RetryAfter is populated from the response's Retry-After header, when
+	// present, for transient errors such as 429 Too Many Requests and 503
+	// Service Unavailable.  See IsTransientError.
+	RetryAfter *time.Duration
+
+	// isProblemDetails records whether this error was decoded from an RFC
+	// 7807 Problem Details body, set by unmarshalError whenever the
+	// response's Content-Type is application/problem+json and the body
+	// decodes; a body that fails to decode leaves it false and sets
+	// ResponseError instead.  It is kept separate from Type/Title/Detail
+	// being non-nil because every field in a Problem Details document, per
+	// the RFC, is optional: a conformant body with only "type" and "status"
+	// set must still be recognized as Problem Details. See IsProblemDetails.
+	isProblemDetails bool
 }
 
 func (e HTTPStatusCodeError) Error() string {
+	if IsProblemDetails(e) {
+		return fmt.Sprintf("Status: %v; Type: %v; Title: %v; Detail: %v; Instance: %v", e.StatusCode, e.Type, e.Title, e.Detail, e.Instance)
+	}
+
 	return fmt.Sprintf("Status: %v; ErrorMessage: %v; Description: %v; ResponseError: %v", e.StatusCode, e.ErrorMessage, e.Description, e.ResponseError)
 }
 
-// IsHTTPError returns whether the error represents an HTTPStatusCodeError.  A
-// client method returning an HTTP error indicates that the broker returned an
-// error code and a correctly formed response body.
-func IsHTTPError(err error) bool {
-	_, ok := err.(HTTPStatusCodeError)
-	return ok
+// Unwrap returns the error that occurred when unmarshalling the response
+// body, if any, allowing errors.Is/errors.As to see through an
+// HTTPStatusCodeError to that underlying cause.
+func (e HTTPStatusCodeError) Unwrap() error {
+	return e.ResponseError
 }
 
-// IsGoneError returns whether the error represents an HTTP GONE status.
-func IsGoneError(err error) bool {
-	statusCodeError, ok := err.(HTTPStatusCodeError)
+// Is reports whether target is an HTTPStatusCodeError identifying the same
+// conventional error as e.  A target's StatusCode must match exactly; if
+// target also sets ErrorMessage, it must match e's ErrorMessage too.  This
+// allows the exported sentinels (ErrGone, ErrAsyncRequired, ...) to match
+// broker responses that differ in Description, ResponseError or any of the
+// Problem Details fields.
+func (e HTTPStatusCodeError) Is(target error) bool {
+	t, ok := target.(HTTPStatusCodeError)
 	if !ok {
 		return false
 	}
 
-	return statusCodeError.StatusCode == http.StatusGone
+	if e.StatusCode != t.StatusCode {
+		return false
+	}
+
+	if t.ErrorMessage == nil {
+		return true
+	}
+
+	return e.ErrorMessage != nil && *e.ErrorMessage == *t.ErrorMessage
 }
 
-// IsConflictError returns whether the error represents a conflict.
-func IsConflictError(err error) bool {
-	statusCodeError, ok := err.(HTTPStatusCodeError)
-	if !ok {
+func stringPtr(s string) *string {
+	return &s
+}
+
+// Exported sentinel errors for use with errors.Is, e.g.:
+//
+//	if errors.Is(err, v2.ErrGone) { ... }
+//
+// These are also what the IsGoneError/IsConflictError/... family of
+// predicates check against internally.
+var (
+	ErrGone            = HTTPStatusCodeError{StatusCode: http.StatusGone}
+	ErrNotFound        = HTTPStatusCodeError{StatusCode: http.StatusNotFound}
+	ErrConflict        = HTTPStatusCodeError{StatusCode: http.StatusConflict}
+	ErrAsyncRequired   = HTTPStatusCodeError{StatusCode: http.StatusUnprocessableEntity, ErrorMessage: stringPtr(AsyncErrorMessage)}
+	ErrAppGUIDRequired = HTTPStatusCodeError{StatusCode: http.StatusUnprocessableEntity, ErrorMessage: stringPtr(AppGUIDRequiredErrorMessage)}
+	ErrConcurrency     = HTTPStatusCodeError{StatusCode: http.StatusUnprocessableEntity, ErrorMessage: stringPtr(ConcurrencyErrorMessage)}
+)
+
+// IsProblemDetails returns whether the error carries an RFC 7807 Problem
+// Details body, as opposed to the conventional OSB {error, description}
+// error shape.
+func IsProblemDetails(err error) bool {
+	var statusCodeError HTTPStatusCodeError
+	if !errors.As(err, &statusCodeError) {
 		return false
 	}
 
-	return statusCodeError.StatusCode == http.StatusConflict
+	return statusCodeError.isProblemDetails
+}
+
+// problemDetails is the RFC 7807 "Problem Details for HTTP APIs" document
+// shape: https://tools.ietf.org/html/rfc7807.
+type problemDetails struct {
+	Type     *string `json:"type,omitempty"`
+	Title    *string `json:"title,omitempty"`
+	Status   *int    `json:"status,omitempty"`
+	Detail   *string `json:"detail,omitempty"`
+	Instance *string `json:"instance,omitempty"`
+}
+
+// osbError is the conventional OSB error body shape: {error, description}.
+type osbError struct {
+	ErrorMessage *string `json:"error,omitempty"`
+	Description  *string `json:"description,omitempty"`
+}
+
+// unmarshalError builds an HTTPStatusCodeError for the given status code,
+// response headers and response body, choosing between the RFC 7807 Problem
+// Details shape and the conventional OSB error shape based on the response's
+// Content-Type header, and populating RetryAfter from the Retry-After header
+// when present.
+func unmarshalError(statusCode int, header http.Header, body []byte) HTTPStatusCodeError {
+	retryAfter := parseRetryAfter(header.Get("Retry-After"))
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err == nil && mediaType == ProblemDetailsContentType {
+		var details problemDetails
+		if err := json.Unmarshal(body, &details); err != nil {
+			return HTTPStatusCodeError{StatusCode: statusCode, ResponseError: err, RetryAfter: retryAfter}
+		}
+
+		return HTTPStatusCodeError{
+			StatusCode:       statusCode,
+			Type:             details.Type,
+			Title:            details.Title,
+			Detail:           details.Detail,
+			Instance:         details.Instance,
+			RetryAfter:       retryAfter,
+			isProblemDetails: true,
+		}
+	}
+
+	var osbErr osbError
+	if err := json.Unmarshal(body, &osbErr); err != nil {
+		return HTTPStatusCodeError{StatusCode: statusCode, ResponseError: err, RetryAfter: retryAfter}
+	}
+
+	return HTTPStatusCodeError{
+		StatusCode:   statusCode,
+		ErrorMessage: osbErr.ErrorMessage,
+		Description:  osbErr.Description,
+		RetryAfter:   retryAfter,
+	}
+}
+
+// IsHTTPError returns whether the error represents an HTTPStatusCodeError,
+// including one wrapped via fmt.Errorf("...: %w", err).  A client method
+// returning an HTTP error indicates that the broker returned an error code;
+// if its response body could not be decoded, ResponseError is set.
+func IsHTTPError(err error) bool {
+	var statusCodeError HTTPStatusCodeError
+	return errors.As(err, &statusCodeError)
+}
+
+// IsGoneError returns whether the error represents an HTTP GONE status.  For
+// Client.PollLastOperation and Client.PollBindingLastOperation, brokers
+// return this status when the resource the operation was polling for no
+// longer exists, which the client should treat as a successful
+// deprovision/unbind.
+func IsGoneError(err error) bool {
+	return errors.Is(err, ErrGone)
+}
+
+// IsNotFoundError returns whether the error represents an HTTP NOT FOUND
+// status.  Unlike IsGoneError, this indicates the broker has no record of
+// the resource ever having existed, as opposed to it having been removed.
+func IsNotFoundError(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsConflictError returns whether the error represents a conflict.
+func IsConflictError(err error) bool {
+	return errors.Is(err, ErrConflict)
 }
 
 const (
@@ -75,44 +245,39 @@ const (
 
 	AppGUIDRequiredErrorMessage     = "RequiresApp"
 	AppGUIDRequiredErrorDescription = "This service supports generation of credentials through binding an application only."
+
+	ConcurrencyErrorMessage     = "ConcurrencyError"
+	ConcurrencyErrorDescription = "The broker is already processing a request for the resource in question. The client should retry later."
 )
 
 // IsAsyncRequiredError returns whether the error corresponds to the
 // conventional way of indicating that a service requires asynchronous
 // operations to perform an action.
 func IsAsyncRequiredError(err error) bool {
-	statusCodeError, ok := err.(HTTPStatusCodeError)
-	if !ok {
-		return false
-	}
-
-	if statusCodeError.StatusCode != http.StatusUnprocessableEntity {
-		return false
-	}
-
-	if *statusCodeError.ErrorMessage != AsyncErrorMessage {
-		return false
-	}
-
-	return *statusCodeError.Description == AsyncErrorDescription
+	return errors.Is(err, ErrAsyncRequired)
 }
 
 // IsAppGUIDRequiredError returns whether the error corresponds to the
 // conventional way of indicating that a service only supports credential-type
 // bindings.
 func IsAppGUIDRequiredError(err error) bool {
-	statusCodeError, ok := err.(HTTPStatusCodeError)
-	if !ok {
-		return false
-	}
-
-	if statusCodeError.StatusCode != http.StatusUnprocessableEntity {
-		return false
-	}
+	return errors.Is(err, ErrAppGUIDRequired)
+}
 
-	if *statusCodeError.ErrorMessage != AppGUIDRequiredErrorMessage {
-		return false
-	}
+// IsAsyncBindingRequiredError returns whether the error corresponds to the
+// conventional way of indicating that a service requires asynchronous
+// support to perform a bind or unbind operation. It uses the same
+// error-message/description convention as IsAsyncRequiredError, since OSB
+// 2.14 reuses the AsyncRequired error for the binding resource as well as
+// the instance resource.
+func IsAsyncBindingRequiredError(err error) bool {
+	return IsAsyncRequiredError(err)
+}
 
-	return *statusCodeError.Description == AppGUIDRequiredErrorDescription
+// IsConcurrencyError returns whether the error corresponds to the
+// conventional way of indicating that the broker is already processing an
+// operation for the resource in question and the client should retry the
+// request later.
+func IsConcurrencyError(err error) bool {
+	return errors.Is(err, ErrConcurrency)
 }
@@ -0,0 +1,84 @@
+package v2
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestUnmarshalError(t *testing.T) {
+	cases := []struct {
+		name              string
+		contentType       string
+		body              string
+		wantProblemDetail bool
+		wantType          *string
+		wantTitle         *string
+		wantErrorMessage  *string
+	}{
+		{
+			name:             "conventional OSB error shape",
+			contentType:      "application/json",
+			body:             `{"error":"AsyncRequired","description":"This service plan requires client support for asynchronous service operations."}`,
+			wantErrorMessage: stringPtr(AsyncErrorMessage),
+		},
+		{
+			name:              "problem details with type and title",
+			contentType:       "application/problem+json",
+			body:              `{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit."}`,
+			wantProblemDetail: true,
+			wantType:          stringPtr("https://example.com/probs/out-of-credit"),
+			wantTitle:         stringPtr("You do not have enough credit."),
+		},
+		{
+			name:              "problem details with only type and status set",
+			contentType:       "application/problem+json",
+			body:              `{"type":"https://example.com/probs/out-of-credit","status":403}`,
+			wantProblemDetail: true,
+			wantType:          stringPtr("https://example.com/probs/out-of-credit"),
+		},
+		{
+			name:              "problem details content type with a parameter",
+			contentType:       "application/problem+json; charset=utf-8",
+			body:              `{"type":"https://example.com/probs/out-of-credit"}`,
+			wantProblemDetail: true,
+			wantType:          stringPtr("https://example.com/probs/out-of-credit"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Content-Type", c.contentType)
+
+			got := unmarshalError(http.StatusForbidden, header, []byte(c.body))
+
+			if got.StatusCode != http.StatusForbidden {
+				t.Errorf("StatusCode = %v, want %v", got.StatusCode, http.StatusForbidden)
+			}
+
+			if IsProblemDetails(got) != c.wantProblemDetail {
+				t.Errorf("IsProblemDetails() = %v, want %v", IsProblemDetails(got), c.wantProblemDetail)
+			}
+
+			if !stringPtrEqual(got.Type, c.wantType) {
+				t.Errorf("Type = %v, want %v", got.Type, c.wantType)
+			}
+
+			if !stringPtrEqual(got.Title, c.wantTitle) {
+				t.Errorf("Title = %v, want %v", got.Title, c.wantTitle)
+			}
+
+			if !stringPtrEqual(got.ErrorMessage, c.wantErrorMessage) {
+				t.Errorf("ErrorMessage = %v, want %v", got.ErrorMessage, c.wantErrorMessage)
+			}
+		})
+	}
+}
+
+func stringPtrEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -0,0 +1,96 @@
+package v2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestHTTPStatusCodeErrorIs(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{
+			name:   "matching status and error message",
+			err:    HTTPStatusCodeError{StatusCode: http.StatusUnprocessableEntity, ErrorMessage: stringPtr(AsyncErrorMessage)},
+			target: ErrAsyncRequired,
+			want:   true,
+		},
+		{
+			name:   "matching status, nil error message on both sides",
+			err:    HTTPStatusCodeError{StatusCode: http.StatusGone},
+			target: ErrGone,
+			want:   true,
+		},
+		{
+			name:   "mismatched error message",
+			err:    HTTPStatusCodeError{StatusCode: http.StatusUnprocessableEntity, ErrorMessage: stringPtr("SomethingElse")},
+			target: ErrAsyncRequired,
+			want:   false,
+		},
+		{
+			name:   "mismatched status code",
+			err:    HTTPStatusCodeError{StatusCode: http.StatusConflict},
+			target: ErrGone,
+			want:   false,
+		},
+		{
+			name:   "error wrapped with fmt.Errorf %w is still recognized",
+			err:    fmt.Errorf("polling last_operation: %w", HTTPStatusCodeError{StatusCode: http.StatusGone}),
+			target: ErrGone,
+			want:   true,
+		},
+		{
+			name:   "not an HTTPStatusCodeError at all",
+			err:    errors.New("boom"),
+			target: ErrGone,
+			want:   false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := errors.Is(c.err, c.target); got != c.want {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", c.err, c.target, got, c.want)
+			}
+		})
+	}
+}
+
+// TestIsXxxPredicatesNilErrorMessageSafe is a regression test: these
+// predicates used to do a direct type assertion followed by an
+// unconditional pointer dereference of ErrorMessage/Description, which
+// panicked whenever a broker omitted those optional fields from its error
+// body.
+func TestIsXxxPredicatesNilErrorMessageSafe(t *testing.T) {
+	err := HTTPStatusCodeError{StatusCode: http.StatusUnprocessableEntity}
+
+	if IsAsyncRequiredError(err) {
+		t.Errorf("IsAsyncRequiredError() = true, want false for a nil ErrorMessage")
+	}
+	if IsAppGUIDRequiredError(err) {
+		t.Errorf("IsAppGUIDRequiredError() = true, want false for a nil ErrorMessage")
+	}
+	if IsConcurrencyError(err) {
+		t.Errorf("IsConcurrencyError() = true, want false for a nil ErrorMessage")
+	}
+}
+
+func TestIsXxxPredicatesSeeThroughWrapping(t *testing.T) {
+	wrapped := fmt.Errorf("provisioning failed: %w", HTTPStatusCodeError{
+		StatusCode:   http.StatusUnprocessableEntity,
+		ErrorMessage: stringPtr(AsyncErrorMessage),
+		Description:  stringPtr(AsyncErrorDescription),
+	})
+
+	if !IsHTTPError(wrapped) {
+		t.Errorf("IsHTTPError() = false, want true for a wrapped HTTPStatusCodeError")
+	}
+	if !IsAsyncRequiredError(wrapped) {
+		t.Errorf("IsAsyncRequiredError() = false, want true for a wrapped HTTPStatusCodeError")
+	}
+}
@@ -0,0 +1,46 @@
+package v2
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// ResponseErrorHandler is invoked by Client whenever a broker responds with
+// a status code that indicates failure, and is responsible for turning the
+// HTTP response into an error value.  Set ClientConfiguration.ResponseErrorHandler
+// to layer in things like Problem Details parsing for non-conformant
+// brokers, custom telemetry/logging, org-specific error shapes returned by
+// broker proxies, or to wrap the default error with additional context via
+// fmt.Errorf("%w", ...).
+//
+// DefaultResponseErrorHandler is used when none is configured, and its
+// behavior is relied upon by the IsGoneError/IsConflictError/... family of
+// predicates, so any replacement should continue to return an
+// HTTPStatusCodeError for responses it does not otherwise understand.
+type ResponseErrorHandler func(response *http.Response) error
+
+// ResponseDecoder is invoked by Client to decode a successful broker
+// response body into v.  Set ClientConfiguration.ResponseDecoder to support
+// brokers that wrap their success responses in an envelope, or to add
+// decode-time validation.
+type ResponseDecoder func(response *http.Response, v interface{}) error
+
+// DefaultResponseErrorHandler is the ResponseErrorHandler used by the client
+// when none is configured.  It builds an HTTPStatusCodeError from the
+// response, choosing between the RFC 7807 Problem Details shape and the
+// conventional OSB error shape based on the response's Content-Type header.
+func DefaultResponseErrorHandler(response *http.Response) error {
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return HTTPStatusCodeError{StatusCode: response.StatusCode, ResponseError: err}
+	}
+
+	return unmarshalError(response.StatusCode, response.Header, body)
+}
+
+// DefaultResponseDecoder is the ResponseDecoder used by the client when none
+// is configured.  It decodes the response body as JSON into v.
+func DefaultResponseDecoder(response *http.Response, v interface{}) error {
+	return json.NewDecoder(response.Body).Decode(v)
+}
@@ -0,0 +1,155 @@
+package v2
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// IsThrottledError returns whether the error represents an HTTP TOO MANY
+// REQUESTS status, indicating that the broker is applying backpressure and
+// the client should slow down.
+func IsThrottledError(err error) bool {
+	var statusCodeError HTTPStatusCodeError
+	if !errors.As(err, &statusCodeError) {
+		return false
+	}
+
+	return statusCodeError.StatusCode == http.StatusTooManyRequests
+}
+
+// IsServerTimeoutError returns whether the error represents an HTTP SERVICE
+// UNAVAILABLE status, indicating that the broker is temporarily unable to
+// handle the request.
+func IsServerTimeoutError(err error) bool {
+	var statusCodeError HTTPStatusCodeError
+	if !errors.As(err, &statusCodeError) {
+		return false
+	}
+
+	return statusCodeError.StatusCode == http.StatusServiceUnavailable
+}
+
+// IsTransientError returns whether the error is one that a client may
+// reasonably retry: IsThrottledError or IsServerTimeoutError.
+func IsTransientError(err error) bool {
+	return IsThrottledError(err) || IsServerTimeoutError(err)
+}
+
+// parseRetryAfter parses a Retry-After header value in either of its two
+// forms: a number of delta-seconds, or an HTTP-date.  It returns nil if the
+// header is absent or malformed.
+func parseRetryAfter(value string) *time.Duration {
+	if value == "" {
+		return nil
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		d := time.Duration(seconds) * time.Second
+		return &d
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		d := time.Until(date)
+		return &d
+	}
+
+	return nil
+}
+
+// RetryPolicy configures automatic retries of idempotent broker calls, such
+// as catalog fetches, last_operation polling, and deprovision, in the face
+// of transient errors (see IsTransientError).
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times an operation is attempted,
+	// including the first attempt.  A value of 1 or less disables retries.
+	MaxAttempts int
+	// BackoffInterval is the base delay between attempts, doubled after each
+	// failed attempt (full jitter exponential backoff).
+	BackoffInterval time.Duration
+	// MaxBackoff caps the computed backoff delay, before jitter is applied.
+	MaxBackoff time.Duration
+	// HonorRetryAfter, when true, uses the RetryAfter duration carried by a
+	// transient HTTPStatusCodeError instead of the computed backoff delay,
+	// when the broker provided one.
+	HonorRetryAfter bool
+}
+
+// DefaultRetryPolicy is a conservative policy suitable as a starting point
+// for retrying idempotent broker calls.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	BackoffInterval: 1 * time.Second,
+	MaxBackoff:      30 * time.Second,
+	HonorRetryAfter: true,
+}
+
+// retryAfter computes the delay to wait after the given failed attempt
+// (1-indexed) of operation, before the next one, honoring RetryAfter on
+// lastErr when configured to do so.
+func (p RetryPolicy) retryAfter(attempt int, lastErr error) time.Duration {
+	if p.HonorRetryAfter {
+		var statusCodeError HTTPStatusCodeError
+		if errors.As(lastErr, &statusCodeError) && statusCodeError.RetryAfter != nil {
+			return *statusCodeError.RetryAfter
+		}
+	}
+
+	// Double backoff once per attempt, stopping as soon as it reaches
+	// MaxBackoff so that a long-running poll loop (e.g. a caller raising
+	// MaxAttempts well past the default for a slow last_operation) can never
+	// double enough times to overflow time.Duration's int64 range and wrap
+	// negative.
+	backoff := p.BackoffInterval
+	for i := 1; i < attempt; i++ {
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+
+		doubled := backoff * 2
+		if doubled <= backoff {
+			// Overflowed; stop doubling and fall through to the MaxBackoff
+			// clamp below.
+			break
+		}
+		backoff = doubled
+	}
+
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if backoff < 0 {
+		backoff = 0
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// Do runs operation, retrying it according to the policy while it returns a
+// transient error (see IsTransientError), and returns the final error if the
+// operation is still failing once MaxAttempts is exhausted.
+func (p RetryPolicy) Do(operation func() error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		lastErr = operation()
+		if lastErr == nil || !IsTransientError(lastErr) {
+			return lastErr
+		}
+
+		if attempt == maxAttempts {
+			break
+		}
+
+		time.Sleep(p.retryAfter(attempt, lastErr))
+	}
+
+	return lastErr
+}
@@ -0,0 +1,148 @@
+package v2
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  *time.Duration
+	}{
+		{name: "absent", value: "", want: nil},
+		{name: "delta seconds", value: "120", want: durationPtr(120 * time.Second)},
+		{name: "malformed", value: "not-a-duration", want: nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := parseRetryAfter(c.value)
+			if (got == nil) != (c.want == nil) {
+				t.Fatalf("parseRetryAfter(%q) = %v, want %v", c.value, got, c.want)
+			}
+			if got != nil && *got != *c.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", c.value, *got, *c.want)
+			}
+		})
+	}
+
+	t.Run("HTTP-date", func(t *testing.T) {
+		future := time.Now().Add(2 * time.Minute).UTC()
+		got := parseRetryAfter(future.Format(http.TimeFormat))
+		if got == nil {
+			t.Fatal("expected a non-nil duration for an HTTP-date Retry-After header")
+		}
+		if *got <= 0 || *got > 2*time.Minute+time.Second {
+			t.Errorf("parseRetryAfter(%q) = %v, want ~2m", future.Format(http.TimeFormat), *got)
+		}
+	})
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	t.Run("does not retry on success", func(t *testing.T) {
+		attempts := 0
+		policy := RetryPolicy{MaxAttempts: 3, BackoffInterval: time.Millisecond}
+
+		err := policy.Do(func() error {
+			attempts++
+			return nil
+		})
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	})
+
+	t.Run("retries transient errors up to MaxAttempts", func(t *testing.T) {
+		attempts := 0
+		policy := RetryPolicy{MaxAttempts: 3, BackoffInterval: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+		transientErr := HTTPStatusCodeError{StatusCode: http.StatusServiceUnavailable}
+
+		err := policy.Do(func() error {
+			attempts++
+			return transientErr
+		})
+
+		if !IsServerTimeoutError(err) {
+			t.Fatalf("expected final error to be a server timeout error, got %v", err)
+		}
+		if attempts != 3 {
+			t.Errorf("attempts = %d, want 3", attempts)
+		}
+	})
+
+	t.Run("does not retry non-transient errors", func(t *testing.T) {
+		attempts := 0
+		policy := RetryPolicy{MaxAttempts: 3, BackoffInterval: time.Millisecond}
+		conflictErr := HTTPStatusCodeError{StatusCode: http.StatusConflict}
+
+		err := policy.Do(func() error {
+			attempts++
+			return conflictErr
+		})
+
+		if !IsConflictError(err) {
+			t.Fatalf("expected final error to be a conflict error, got %v", err)
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	})
+
+	t.Run("does not overflow or panic for a large attempt count", func(t *testing.T) {
+		// Regression test: BackoffInterval doubled once per attempt used to
+		// overflow time.Duration's int64 range once a 1ms interval had been
+		// doubled about 44 times, well before 70 attempts, producing a
+		// negative backoff that both defeated the MaxBackoff cap and made
+		// rand.Int63n panic.
+		attempts := 0
+		policy := RetryPolicy{MaxAttempts: 70, BackoffInterval: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+		transientErr := HTTPStatusCodeError{StatusCode: http.StatusTooManyRequests}
+
+		err := policy.Do(func() error {
+			attempts++
+			return transientErr
+		})
+
+		if !IsThrottledError(err) {
+			t.Fatalf("expected final error to be a throttled error, got %v", err)
+		}
+		if attempts != 70 {
+			t.Errorf("attempts = %d, want 70", attempts)
+		}
+	})
+
+	t.Run("honors Retry-After when configured", func(t *testing.T) {
+		attempts := 0
+		policy := RetryPolicy{MaxAttempts: 2, BackoffInterval: time.Hour, HonorRetryAfter: true}
+		retryAfter := time.Millisecond
+		transientErr := HTTPStatusCodeError{StatusCode: http.StatusTooManyRequests, RetryAfter: &retryAfter}
+
+		start := time.Now()
+		err := policy.Do(func() error {
+			attempts++
+			if attempts == 1 {
+				return transientErr
+			}
+			return nil
+		})
+		elapsed := time.Since(start)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if elapsed > time.Second {
+			t.Errorf("elapsed = %v, want well under the 1h BackoffInterval, since RetryAfter should have been honored", elapsed)
+		}
+	})
+}